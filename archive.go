@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const archivedTemplateDir = ".promptgen/templates/archived"
+
+func getArchivedVersionPath(name string, version int) string {
+	home, _ := os.UserHomeDir()
+	filename := fmt.Sprintf("%s_v%d.json", name, version)
+	return filepath.Join(home, archivedTemplateDir, filename)
+}
+
+func ensureArchivedTemplateDir() error {
+	home, _ := os.UserHomeDir()
+	return os.MkdirAll(filepath.Join(home, archivedTemplateDir), os.ModePerm)
+}
+
+func isVersionArchived(name string, version int) bool {
+	_, err := os.Stat(getArchivedVersionPath(name, version))
+	return err == nil
+}
+
+// listVersionNumbers returns the version numbers on disk for name, sorted
+// ascending. Archived versions are included only when includeArchived is set.
+func listVersionNumbers(name string, includeArchived bool) []int {
+	var versions []int
+	home, _ := os.UserHomeDir()
+
+	addFrom := func(pattern string) {
+		files, _ := filepath.Glob(pattern)
+		for _, f := range files {
+			base := strings.TrimSuffix(filepath.Base(f), ".json")
+			parts := strings.Split(base, "_v")
+			if len(parts) != 2 {
+				continue
+			}
+			n, err := strconv.Atoi(parts[1])
+			if err != nil {
+				continue
+			}
+			versions = append(versions, n)
+		}
+	}
+
+	addFrom(filepath.Join(home, templateDir, fmt.Sprintf("%s_v*.json", name)))
+	if includeArchived {
+		addFrom(filepath.Join(home, archivedTemplateDir, fmt.Sprintf("%s_v*.json", name)))
+	}
+
+	sort.Ints(versions)
+	return versions
+}
+
+func setArchivedFlag(path string, archived bool) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var tpl PromptTemplate
+	if err := json.Unmarshal(data, &tpl); err != nil {
+		return err
+	}
+	tpl.Archived = archived
+	out, err := json.MarshalIndent(tpl, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, out, 0644)
+}
+
+func archiveVersion(name string, version int) error {
+	src := getTemplateVersionPath(name, version)
+	if _, err := os.Stat(src); err != nil {
+		return fmt.Errorf("version %d of %q not found", version, name)
+	}
+	if err := ensureArchivedTemplateDir(); err != nil {
+		return err
+	}
+	if err := setArchivedFlag(src, true); err != nil {
+		return err
+	}
+	dst := getArchivedVersionPath(name, version)
+	return os.Rename(src, dst)
+}
+
+func unarchiveVersion(name string, version int) error {
+	src := getArchivedVersionPath(name, version)
+	if _, err := os.Stat(src); err != nil {
+		return fmt.Errorf("version %d of %q is not archived", version, name)
+	}
+	if err := ensureTemplateDir(); err != nil {
+		return err
+	}
+	if err := setArchivedFlag(src, false); err != nil {
+		return err
+	}
+	dst := getTemplateVersionPath(name, version)
+	return os.Rename(src, dst)
+}
+
+// archiveCmd implements `promptgen archive NAME VERSION...`.
+func archiveCmd() {
+	if len(os.Args) < 4 {
+		fmt.Println("Usage: promptgen archive NAME VERSION...")
+		return
+	}
+	name := os.Args[2]
+	for _, arg := range os.Args[3:] {
+		version, err := strconv.Atoi(arg)
+		if err != nil {
+			fmt.Println("Invalid version number:", arg)
+			continue
+		}
+		if err := archiveVersion(name, version); err != nil {
+			fmt.Println("Error archiving:", err)
+			continue
+		}
+		fmt.Printf("Archived %s version %d.\n", name, version)
+	}
+}
+
+// unarchiveCmd implements `promptgen unarchive NAME VERSION...`.
+func unarchiveCmd() {
+	if len(os.Args) < 4 {
+		fmt.Println("Usage: promptgen unarchive NAME VERSION...")
+		return
+	}
+	name := os.Args[2]
+	for _, arg := range os.Args[3:] {
+		version, err := strconv.Atoi(arg)
+		if err != nil {
+			fmt.Println("Invalid version number:", arg)
+			continue
+		}
+		if err := unarchiveVersion(name, version); err != nil {
+			fmt.Println("Error unarchiving:", err)
+			continue
+		}
+		fmt.Printf("Unarchived %s version %d.\n", name, version)
+	}
+}
+
+// pruneCmd implements `promptgen prune NAME --keep N`, archiving all but the
+// newest N versions.
+func pruneCmd() {
+	if len(os.Args) < 5 || os.Args[3] != "--keep" {
+		fmt.Println("Usage: promptgen prune NAME --keep N")
+		return
+	}
+	name := os.Args[2]
+	keep, err := strconv.Atoi(os.Args[4])
+	if err != nil || keep < 0 {
+		fmt.Println("Invalid --keep value:", os.Args[4])
+		return
+	}
+
+	versions := listVersionNumbers(name, false)
+	if len(versions) <= keep {
+		fmt.Printf("Nothing to prune: %q has %d version(s), keeping %d.\n", name, len(versions), keep)
+		return
+	}
+
+	toArchive := versions[:len(versions)-keep]
+	for _, v := range toArchive {
+		if err := archiveVersion(name, v); err != nil {
+			fmt.Println("Error archiving:", err)
+			continue
+		}
+		fmt.Printf("Archived %s version %d.\n", name, v)
+	}
+}
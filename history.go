@@ -0,0 +1,367 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/atotto/clipboard"
+)
+
+const historyFile = ".promptgen/history.jsonl"
+
+// HistoryRecord is one append-only entry of a `generate` run.
+type HistoryRecord struct {
+	ID         int                    `json:"id"`
+	Timestamp  time.Time              `json:"timestamp"`
+	Template   string                 `json:"template"`
+	Version    int                    `json:"version"`
+	Variables  map[string]interface{} `json:"variables,omitempty"`
+	Rendered   string                 `json:"rendered"`
+	SHA256     string                 `json:"sha256"`
+	DurationMS int64                  `json:"duration_ms"`
+}
+
+func getHistoryPath() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, historyFile)
+}
+
+func loadHistory() ([]HistoryRecord, error) {
+	f, err := os.Open(getHistoryPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []HistoryRecord
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var rec HistoryRecord
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			continue
+		}
+		records = append(records, rec)
+	}
+	return records, scanner.Err()
+}
+
+func appendHistoryRecord(tpl PromptTemplate, variables map[string]interface{}, rendered string, duration time.Duration) error {
+	path := getHistoryPath()
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+
+	records, err := loadHistory()
+	if err != nil {
+		return err
+	}
+	nextID := 1
+	if len(records) > 0 {
+		nextID = records[len(records)-1].ID + 1
+	}
+
+	sum := sha256.Sum256([]byte(rendered))
+	rec := HistoryRecord{
+		ID:         nextID,
+		Timestamp:  time.Now(),
+		Template:   tpl.Name,
+		Version:    tpl.Version,
+		Variables:  variables,
+		Rendered:   rendered,
+		SHA256:     hex.EncodeToString(sum[:]),
+		DurationMS: duration.Milliseconds(),
+	}
+
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+func findHistoryRecord(records []HistoryRecord, id int) (HistoryRecord, bool) {
+	for _, r := range records {
+		if r.ID == id {
+			return r, true
+		}
+	}
+	return HistoryRecord{}, false
+}
+
+// showHistory implements `promptgen history [--template NAME] [--since DUR]
+// [--grep PATTERN] [--limit N] [--format json|table|template=<gotmpl>]`.
+func showHistory() {
+	records, err := loadHistory()
+	if err != nil {
+		fmt.Println("Error reading history:", err)
+		return
+	}
+	if len(records) == 0 {
+		fmt.Println("No history found.")
+		return
+	}
+
+	var (
+		templateFilter string
+		since          time.Duration
+		grepPattern    *regexp.Regexp
+		limit          int
+		format         = "table"
+	)
+
+	args := os.Args[2:]
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--template" && i+1 < len(args):
+			templateFilter = args[i+1]
+			i++
+		case args[i] == "--since" && i+1 < len(args):
+			d, err := time.ParseDuration(args[i+1])
+			if err != nil {
+				fmt.Println("Invalid --since duration:", args[i+1])
+				return
+			}
+			since = d
+			i++
+		case args[i] == "--grep" && i+1 < len(args):
+			re, err := regexp.Compile(args[i+1])
+			if err != nil {
+				fmt.Println("Invalid --grep pattern:", err)
+				return
+			}
+			grepPattern = re
+			i++
+		case args[i] == "--limit" && i+1 < len(args):
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil {
+				fmt.Println("Invalid --limit:", args[i+1])
+				return
+			}
+			limit = n
+			i++
+		case strings.HasPrefix(args[i], "--format"):
+			if v, ok := strings.CutPrefix(args[i], "--format="); ok {
+				format = v
+			} else if i+1 < len(args) {
+				format = args[i+1]
+				i++
+			}
+		}
+	}
+
+	filtered := records[:0:0]
+	for _, r := range records {
+		if templateFilter != "" && r.Template != templateFilter {
+			continue
+		}
+		if since > 0 && time.Since(r.Timestamp) > since {
+			continue
+		}
+		if grepPattern != nil && !grepPattern.MatchString(r.Rendered) && !grepPattern.MatchString(r.Template) {
+			continue
+		}
+		filtered = append(filtered, r)
+	}
+
+	if limit > 0 && len(filtered) > limit {
+		filtered = filtered[len(filtered)-limit:]
+	}
+
+	if len(filtered) == 0 {
+		fmt.Println("No history entries match.")
+		return
+	}
+
+	switch {
+	case format == "json":
+		data, _ := json.MarshalIndent(filtered, "", "  ")
+		fmt.Println(string(data))
+	case strings.HasPrefix(format, "template="):
+		gotmpl := strings.TrimPrefix(format, "template=")
+		tmpl, err := template.New("history").Parse(gotmpl)
+		if err != nil {
+			fmt.Println("Invalid --format template:", err)
+			return
+		}
+		for _, r := range filtered {
+			if err := tmpl.Execute(os.Stdout, r); err != nil {
+				fmt.Println("Template execution error:", err)
+				return
+			}
+			fmt.Println()
+		}
+	default: // table
+		fmt.Printf("%-5s %-25s %-20s %-8s %-10s %s\n", "ID", "TIMESTAMP", "TEMPLATE", "VERSION", "DURATION", "SHA256")
+		for _, r := range filtered {
+			fmt.Printf("%-5d %-25s %-20s %-8d %-10s %s\n",
+				r.ID, r.Timestamp.Format(time.RFC3339), r.Template, r.Version,
+				fmt.Sprintf("%dms", r.DurationMS), r.SHA256[:12])
+		}
+	}
+}
+
+// rerunHistory implements `promptgen rerun ID [--original] [--allow-exec] [--allow-file] [--allow-env]`.
+func rerunHistory() {
+	if len(os.Args) < 3 {
+		fmt.Println("Usage: promptgen rerun ID [--original] [--allow-exec] [--allow-file] [--allow-env]")
+		return
+	}
+	id, err := strconv.Atoi(os.Args[2])
+	if err != nil {
+		fmt.Println("Invalid history ID:", os.Args[2])
+		return
+	}
+	rest, allowExec, allowFile, allowEnv := extractRenderFlags(os.Args[3:])
+	useOriginal := len(rest) >= 1 && rest[0] == "--original"
+
+	records, err := loadHistory()
+	if err != nil {
+		fmt.Println("Error reading history:", err)
+		return
+	}
+	rec, ok := findHistoryRecord(records, id)
+	if !ok {
+		fmt.Printf("No history entry with ID %d.\n", id)
+		return
+	}
+
+	var tpl PromptTemplate
+	if useOriginal {
+		if isVersionArchived(rec.Template, rec.Version) {
+			fmt.Printf("Version %d of %q is archived; unarchive it before rerunning with --original.\n", rec.Version, rec.Template)
+			return
+		}
+		tpl, err = loadTemplateVersion(rec.Template, rec.Version)
+	} else {
+		tpl, err = loadTemplate(rec.Template)
+	}
+	if err != nil {
+		fmt.Println("Error loading template:", err)
+		return
+	}
+
+	start := time.Now()
+	renderer := NewRenderer(allowExec, allowFile, allowEnv)
+	promptStr, err := renderer.Render(tpl, coerceHistoryVariables(tpl, rec.Variables))
+	if err != nil {
+		fmt.Println("Template render error:", err)
+		return
+	}
+
+	fmt.Printf("\nReplayed history #%d against template %q (version %d):\n", id, tpl.Name, tpl.Version)
+	fmt.Println(promptStr)
+
+	if err := clipboard.WriteAll(promptStr); err != nil {
+		fmt.Println("Warning: failed to copy to clipboard:", err)
+	} else {
+		fmt.Println("\nPrompt copied to clipboard!")
+	}
+
+	if err := appendHistoryRecord(tpl, rec.Variables, promptStr, time.Since(start)); err != nil {
+		fmt.Println("Warning: failed to record history:", err)
+	}
+}
+
+// diffHistory implements `promptgen diff ID1 ID2`.
+func diffHistory() {
+	if len(os.Args) < 4 {
+		fmt.Println("Usage: promptgen diff ID1 ID2")
+		return
+	}
+	id1, err1 := strconv.Atoi(os.Args[2])
+	id2, err2 := strconv.Atoi(os.Args[3])
+	if err1 != nil || err2 != nil {
+		fmt.Println("Usage: promptgen diff ID1 ID2")
+		return
+	}
+
+	records, err := loadHistory()
+	if err != nil {
+		fmt.Println("Error reading history:", err)
+		return
+	}
+	rec1, ok1 := findHistoryRecord(records, id1)
+	rec2, ok2 := findHistoryRecord(records, id2)
+	if !ok1 || !ok2 {
+		fmt.Println("One or both history IDs were not found.")
+		return
+	}
+
+	fmt.Printf("--- #%d (%s)\n", rec1.ID, rec1.Timestamp.Format(time.RFC3339))
+	fmt.Printf("+++ #%d (%s)\n", rec2.ID, rec2.Timestamp.Format(time.RFC3339))
+	fmt.Print(unifiedDiff(rec1.Rendered, rec2.Rendered))
+}
+
+// unifiedDiff renders a minimal unified-style line diff between a and b,
+// based on a longest-common-subsequence line alignment.
+func unifiedDiff(a, b string) string {
+	aLines := strings.Split(a, "\n")
+	bLines := strings.Split(b, "\n")
+
+	n, m := len(aLines), len(bLines)
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if aLines[i] == bLines[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var out strings.Builder
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case aLines[i] == bLines[j]:
+			out.WriteString("  " + aLines[i] + "\n")
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			out.WriteString("- " + aLines[i] + "\n")
+			i++
+		default:
+			out.WriteString("+ " + bLines[j] + "\n")
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		out.WriteString("- " + aLines[i] + "\n")
+	}
+	for ; j < m; j++ {
+		out.WriteString("+ " + bLines[j] + "\n")
+	}
+	return out.String()
+}
@@ -0,0 +1,45 @@
+package main
+
+import "testing"
+
+// TestRerunSurvivesIntComparisonAfterJSONRoundTrip exercises the full path a
+// real int-typed conditional variable takes: detectVariableRefs must pick up
+// .Count from inside the gt comparison (so add/update actually declare it),
+// and coerceHistoryVariables must restore it from the float64 JSON gives back
+// on replay. Either half missing reproduces the original rerun failure.
+func TestRerunSurvivesIntComparisonAfterJSONRoundTrip(t *testing.T) {
+	body := "{{if gt .Count 10}}big{{else}}small{{end}}"
+
+	refs := detectVariableRefs(body)
+	if len(refs) != 1 || refs[0] != "Count" {
+		t.Fatalf("detectVariableRefs(%q) = %v, want [Count]", body, refs)
+	}
+
+	tpl := PromptTemplate{
+		Name:     "numtest",
+		Template: body,
+		Variables: []Variable{
+			{Name: "Count", Type: "int"},
+		},
+	}
+
+	renderer := NewRenderer(false, false, false)
+
+	original := map[string]interface{}{"Count": 15}
+	want, err := renderer.Render(tpl, original)
+	if err != nil {
+		t.Fatalf("initial render failed: %v", err)
+	}
+
+	// Simulates what json.Unmarshal produces after Variables round-trips
+	// through the history file: ints decode as float64.
+	replayed := map[string]interface{}{"Count": float64(15)}
+	got, err := renderer.Render(tpl, coerceHistoryVariables(tpl, replayed))
+	if err != nil {
+		t.Fatalf("rerun render failed: %v", err)
+	}
+
+	if got != want {
+		t.Errorf("rerun render = %q, want %q", got, want)
+	}
+}
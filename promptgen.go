@@ -2,7 +2,6 @@ package main
 
 import (
 	"bufio"
-	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -12,7 +11,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
-	"text/template"
+	"time"
 
 	"github.com/atotto/clipboard"
 )
@@ -20,9 +19,11 @@ import (
 const templateDir = ".promptgen/templates"
 
 type PromptTemplate struct {
-	Name     string `json:"name"`
-	Version  int    `json:"version"`
-	Template string `json:"template"`
+	Name      string     `json:"name"`
+	Version   int        `json:"version"`
+	Template  string     `json:"template"`
+	Variables []Variable `json:"variables,omitempty"`
+	Archived  bool       `json:"archived,omitempty"`
 }
 
 func main() {
@@ -46,6 +47,8 @@ func main() {
 		generatePrompt()
 	case "review":
 		reviewTemplate()
+	case "test":
+		testTemplateSchema()
 	case "completion":
 		completion()
 	case "history":
@@ -54,6 +57,24 @@ func main() {
 		listVersions()
 	case "view":
 		viewVersion()
+	case "source":
+		sourceCmd()
+	case "pull":
+		pullCmd()
+	case "sync":
+		syncCmd()
+	case "push":
+		pushCmd()
+	case "rerun":
+		rerunHistory()
+	case "diff":
+		diffHistory()
+	case "archive":
+		archiveCmd()
+	case "unarchive":
+		unarchiveCmd()
+	case "prune":
+		pruneCmd()
 	default:
 		usage()
 		os.Exit(1)
@@ -68,13 +89,43 @@ Commands:
   list                     List all prompt templates with versions
   delete NAME              Delete a prompt template and all versions
   update NAME              Update a prompt template by name (increments version)
-  generate NAME [TEXT_INPUT | --clip]
-                           Generate prompt from template; if TEXT_INPUT omitted, opens editor, or use --clip for clipboard input
-  review NAME              Show latest version content of a prompt template
-  versions NAME            List all versions of a template
-  view NAME VERSION        View a specific version of a template
+  generate NAME [key=value ...] [--vars file.json] [--allow-exec] [--allow-file] [--allow-env]
+                           [TEXT_INPUT | --clip]
+                           Generate prompt from template. For templates with a declared
+                           variable schema, supply key=value pairs and/or --vars file.json;
+                           anything missing is prompted for interactively. Templates with
+                           no declared variables fall back to TEXT_INPUT/editor/--clip. Templates may
+                           use env/file/clip/now/date/exec/include and basic string helpers;
+                           env/file/exec are disabled until their matching --allow-* flag is
+                           passed, since a pulled registry template runs on your machine.
+                           An optional NAME.data.json sidecar supplies default variable values.
+  review NAME [--dry-run]  Show latest version content, or render it with placeholder
+                           variable values via --dry-run
+  test NAME                Validate a template's variable schema against its body
+  versions NAME [--all|--archived]
+                           List all versions of a template (archived hidden by default)
+  view NAME VERSION [--all|--archived]
+                           View a specific version of a template
+  archive NAME VERSION...  Archive one or more versions of a template
+  unarchive NAME VERSION...
+                           Restore one or more archived versions
+  prune NAME --keep N      Archive all but the newest N versions
   completion SHELL         Output shell completion script (bash or zsh)
-  history                  Show prompt generation history
+  history [--template NAME] [--since DUR] [--grep PATTERN] [--limit N]
+          [--format json|table|template=<gotmpl>]
+                           Show prompt generation history
+  rerun ID [--original] [--allow-exec] [--allow-file] [--allow-env]
+                           Replay a past generation (use --original for the template
+                           version recorded in history instead of the current one)
+  diff ID1 ID2             Show a unified diff between two rendered outputs
+
+  source add NAME URL      Add a template registry source (git/http/tar)
+  source list              List configured sources
+  pull SOURCE[/TEMPLATE]   Fetch a source's registry into ~/.promptgen/registries
+  sync                     Refresh all git-backed sources
+  push SOURCE              Commit and push local edits in a git-backed registry
+
+  generate also resolves "source/name" short-names against pulled registries.
 `)
 }
 
@@ -99,7 +150,7 @@ func ensureTemplateDir() error {
 
 func loadTemplate(name string) (PromptTemplate, error) {
 	var t PromptTemplate
-	data, err := os.ReadFile(getTemplatePath(name))
+	data, err := os.ReadFile(resolveTemplatePath(name))
 	if err != nil {
 		return t, err
 	}
@@ -109,7 +160,11 @@ func loadTemplate(name string) (PromptTemplate, error) {
 
 func loadTemplateVersion(name string, version int) (PromptTemplate, error) {
 	var t PromptTemplate
-	data, err := os.ReadFile(getTemplateVersionPath(name, version))
+	path := getTemplateVersionPath(name, version)
+	if _, err := os.Stat(path); err != nil {
+		path = getArchivedVersionPath(name, version)
+	}
+	data, err := os.ReadFile(path)
 	if err != nil {
 		return t, err
 	}
@@ -148,9 +203,10 @@ func addTemplate() {
 	}
 
 	tpl := PromptTemplate{
-		Name:     name,
-		Version:  1,
-		Template: string(content),
+		Name:      name,
+		Version:   1,
+		Template:  string(content),
+		Variables: promptForVariableSchema(reader, string(content), nil),
 	}
 
 	data, _ := json.MarshalIndent(tpl, "", "  ")
@@ -203,6 +259,25 @@ func listTemplates() {
 			fmt.Printf(" - %s (version %d)\n", t.Name, t.Version)
 		}
 	}
+
+	sources, _ := loadSources()
+	for _, s := range sources {
+		regFiles, _ := filepath.Glob(filepath.Join(getRegistryDir(s.Name), "*.json"))
+		for _, f := range regFiles {
+			base := filepath.Base(f)
+			if strings.Contains(base, "_v") {
+				continue
+			}
+			data, err := os.ReadFile(f)
+			if err != nil {
+				continue
+			}
+			var t PromptTemplate
+			if err := json.Unmarshal(data, &t); err == nil {
+				fmt.Printf(" - %s/%s (version %d)\n", s.Name, t.Name, t.Version)
+			}
+		}
+	}
 }
 
 func deleteTemplate() {
@@ -225,18 +300,25 @@ func deleteTemplate() {
 		return
 	}
 
-	if len(files) == 0 {
+	archivedPattern := filepath.Join(home, archivedTemplateDir, fmt.Sprintf("%s*.json", name))
+	archivedFiles, err := filepath.Glob(archivedPattern)
+	if err != nil {
+		fmt.Println("Error listing archived template files:", err)
+		return
+	}
+
+	if len(files) == 0 && len(archivedFiles) == 0 {
 		fmt.Println("No such template found:", name)
 		return
 	}
 
-	for _, f := range files {
+	for _, f := range append(files, archivedFiles...) {
 		if err := os.Remove(f); err != nil {
 			fmt.Println("Error deleting file:", f, err)
 		}
 	}
 
-	fmt.Println("Deleted template and all versions:", name)
+	fmt.Printf("Deleted template and all versions (including %d archived) for %q.\n", len(archivedFiles), name)
 }
 
 func updateTemplate() {
@@ -262,6 +344,7 @@ func updateTemplate() {
 
 	tpl.Template = string(content)
 	tpl.Version++ // increment version
+	tpl.Variables = promptForVariableSchema(bufio.NewReader(os.Stdin), tpl.Template, tpl.Variables)
 
 	data, _ := json.MarshalIndent(tpl, "", "  ")
 
@@ -286,60 +369,77 @@ func updateTemplate() {
 
 func generatePrompt() {
 	if len(os.Args) < 3 {
-		fmt.Println("Usage: promptgen generate NAME [TEXT_INPUT | --clip]")
+		fmt.Println("Usage: promptgen generate NAME [key=value ...] [--vars file.json] [--allow-exec] [TEXT_INPUT | --clip]")
 		return
 	}
 	name := os.Args[2]
+	start := time.Now()
 
 	tpl, err := loadTemplate(name)
 	if err != nil {
 		fmt.Println("Error loading template:", err)
 		return
 	}
+	if isVersionArchived(name, tpl.Version) {
+		fmt.Printf("Version %d of %q is archived; unarchive it before generating.\n", tpl.Version, name)
+		return
+	}
 
-	var input string
+	args, allowExec, allowFile, allowEnv := extractRenderFlags(os.Args[3:])
 
-	// Check for --clip flag
-	if len(os.Args) >= 4 && os.Args[3] == "--clip" {
-		input, err = clipboard.ReadAll()
+	sidecar, err := loadTemplateDataSidecar(name)
+	if err != nil {
+		fmt.Println("Error reading data sidecar:", err)
+		return
+	}
+
+	var data map[string]interface{}
+	if len(tpl.Variables) == 0 {
+		// Legacy single-slot template: <input> only.
+		input, err := resolveLegacyInput(args)
 		if err != nil {
-			fmt.Println("Failed to read from clipboard:", err)
+			fmt.Println(err)
 			return
 		}
-		fmt.Println("(Using input from clipboard)")
-	} else if len(os.Args) >= 4 {
-		input = os.Args[3]
+		data = sidecar
+		data["Input"] = input
 	} else {
-		input, err = openEditorForInput()
-		if err != nil || strings.TrimSpace(input) == "" {
-			input, err = clipboard.ReadAll()
+		provided, varsFile, _ := parseVariableArgs(args)
+		if varsFile != "" {
+			fileValues, err := loadVarsFile(varsFile)
 			if err != nil {
-				fmt.Println("Failed to read from clipboard:", err)
+				fmt.Println("Error reading --vars file:", err)
 				return
 			}
-			fmt.Println("(Using input from clipboard as fallback)")
-		} else {
-			fmt.Println("(Using input from editor)")
+			for k, v := range fileValues {
+				if _, ok := provided[k]; !ok {
+					provided[k] = v
+				}
+			}
+		}
+		for k, v := range sidecar {
+			if _, ok := provided[k]; !ok {
+				provided[k] = fmt.Sprintf("%v", v)
+			}
+		}
+		resolved, err := resolveVariables(tpl, provided)
+		if err != nil {
+			fmt.Println("Error resolving variables:", err)
+			return
+		}
+		data = sidecar
+		for k, v := range resolved {
+			data[k] = v
 		}
 	}
 
-	// Replace <input> with Go template syntax
-	normalizedTpl := strings.ReplaceAll(tpl.Template, "<input>", "{{.Input}}")
-	data := map[string]string{"Input": input}
-
-	tmpl, err := template.New(tpl.Name).Parse(normalizedTpl)
+	renderer := NewRenderer(allowExec, allowFile, allowEnv)
+	promptStr, err := renderer.Render(tpl, data)
 	if err != nil {
-		fmt.Println("Template parse error:", err)
+		fmt.Println("Template render error:", err)
 		return
 	}
 
-	var output bytes.Buffer
-	if err := tmpl.Execute(&output, data); err != nil {
-		fmt.Println("Template execution error:", err)
-		return
-	}
-
-	promptStr := output.String()
 	fmt.Printf("\nGenerated Prompt (from template version %d):\n", tpl.Version)
 	fmt.Println(promptStr)
 
@@ -348,11 +448,63 @@ func generatePrompt() {
 	} else {
 		fmt.Println("\nPrompt copied to clipboard!")
 	}
+
+	if err := appendHistoryRecord(tpl, data, promptStr, time.Since(start)); err != nil {
+		fmt.Println("Warning: failed to record history:", err)
+	}
+}
+
+// extractRenderFlags strips --allow-exec/--allow-file/--allow-env from args,
+// reporting which were present. Each renderer helper that touches the local
+// machine on a template's behalf requires its own opt-in flag.
+func extractRenderFlags(args []string) (rest []string, allowExec, allowFile, allowEnv bool) {
+	for _, a := range args {
+		switch a {
+		case "--allow-exec":
+			allowExec = true
+		case "--allow-file":
+			allowFile = true
+		case "--allow-env":
+			allowEnv = true
+		default:
+			rest = append(rest, a)
+		}
+	}
+	return rest, allowExec, allowFile, allowEnv
+}
+
+// resolveLegacyInput handles the original, pre-schema single <input>
+// placeholder: a positional TEXT_INPUT arg, --clip, or editor/clipboard
+// fallback when nothing is supplied.
+func resolveLegacyInput(args []string) (string, error) {
+	if len(args) >= 1 && args[0] == "--clip" {
+		input, err := clipboard.ReadAll()
+		if err != nil {
+			return "", fmt.Errorf("failed to read from clipboard: %w", err)
+		}
+		fmt.Println("(Using input from clipboard)")
+		return input, nil
+	}
+	if len(args) >= 1 {
+		return args[0], nil
+	}
+
+	input, err := openEditorForInput()
+	if err != nil || strings.TrimSpace(input) == "" {
+		input, err = clipboard.ReadAll()
+		if err != nil {
+			return "", fmt.Errorf("failed to read from clipboard: %w", err)
+		}
+		fmt.Println("(Using input from clipboard as fallback)")
+		return input, nil
+	}
+	fmt.Println("(Using input from editor)")
+	return input, nil
 }
 
 func reviewTemplate() {
 	if len(os.Args) < 3 {
-		fmt.Println("Usage: promptgen review NAME")
+		fmt.Println("Usage: promptgen review NAME [--dry-run]")
 		return
 	}
 	name := os.Args[2]
@@ -363,6 +515,18 @@ func reviewTemplate() {
 		return
 	}
 
+	if len(os.Args) >= 4 && os.Args[3] == "--dry-run" {
+		renderer := NewRenderer(false, false, false)
+		out, err := renderer.Render(tpl, placeholderData(tpl))
+		if err != nil {
+			fmt.Println("Template render error:", err)
+			return
+		}
+		fmt.Printf("Template %q (version %d) dry-run render:\n\n", name, tpl.Version)
+		fmt.Println(out)
+		return
+	}
+
 	fmt.Printf("Template %q (version %d) content:\n\n", name, tpl.Version)
 	fmt.Println(tpl.Template)
 }
@@ -394,7 +558,7 @@ _promptgen_completions() {
 	COMPREPLY=()
 	cur="${COMP_WORDS[COMP_CWORD]}"
 	prev="${COMP_WORDS[COMP_CWORD-1]}"
-	cmds="list add update delete generate review versions view completion history"
+	cmds="list add update delete generate review test versions view completion history rerun diff source pull sync push archive unarchive prune"
 
 	# load templates from your data directory
 	templates="$(promptgen list | tail -n +2 | awk '{print $2}')"
@@ -405,7 +569,7 @@ _promptgen_completions() {
 	fi
 
 	case "${COMP_WORDS[1]}" in
-		generate|update|delete|review|versions|view)
+		generate|update|delete|review|test|versions|view|archive|unarchive|prune)
 			COMPREPLY=( $(compgen -W "$templates" -- "$cur") )
 			return 0
 			;;
@@ -424,7 +588,7 @@ func zshCompletionScript() string {
 	return `#compdef promptgen
 
 _arguments \
-  '1:command:(list add update delete generate review versions view completion history)' \
+  '1:command:(list add update delete generate review test versions view completion history rerun diff source pull sync push archive unarchive prune)' \
   '2:template:->templates' \
   '3:arg:->args'
 
@@ -442,56 +606,33 @@ esac
 `
 }
 
-func showHistory() {
-	historyPath := getHistoryPath()
-	data, err := os.ReadFile(historyPath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			fmt.Println("No history found.")
-		} else {
-			fmt.Println("Error reading history:", err)
-		}
-		return
-	}
-
-	fmt.Println("Prompt Generation History:\n")
-	fmt.Println(string(data))
-}
-
 func listVersions() {
 	if len(os.Args) < 3 {
-		fmt.Println("Usage: promptgen versions NAME")
+		fmt.Println("Usage: promptgen versions NAME [--all|--archived]")
 		return
 	}
 	name := os.Args[2]
+	includeArchived := hasIncludeArchivedFlag(os.Args[3:])
 
-	home, _ := os.UserHomeDir()
-	pattern := filepath.Join(home, templateDir, fmt.Sprintf("%s_v*.json", name))
-	files, err := filepath.Glob(pattern)
-	if err != nil {
-		fmt.Println("Error listing versions:", err)
-		return
-	}
-	if len(files) == 0 {
+	versions := listVersionNumbers(name, includeArchived)
+	if len(versions) == 0 {
 		fmt.Printf("No versions found for template %q\n", name)
 		return
 	}
 
 	fmt.Printf("Versions for template %q:\n", name)
-	for _, f := range files {
-		base := filepath.Base(f) // e.g. "example_v3.json"
-		verStr := strings.TrimSuffix(base, ".json")
-		verParts := strings.Split(verStr, "_v")
-		if len(verParts) != 2 {
-			continue
+	for _, v := range versions {
+		if isVersionArchived(name, v) {
+			fmt.Printf("Version %d (archived)\n", v)
+		} else {
+			fmt.Println("Version", v)
 		}
-		fmt.Println("Version", verParts[1])
 	}
 }
 
 func viewVersion() {
 	if len(os.Args) < 4 {
-		fmt.Println("Usage: promptgen view NAME VERSION")
+		fmt.Println("Usage: promptgen view NAME VERSION [--all|--archived]")
 		return
 	}
 	name := os.Args[2]
@@ -501,6 +642,12 @@ func viewVersion() {
 		fmt.Println("Invalid version number:", versionStr)
 		return
 	}
+	includeArchived := hasIncludeArchivedFlag(os.Args[4:])
+
+	if isVersionArchived(name, version) && !includeArchived {
+		fmt.Printf("Version %d of template %q is archived; pass --all or --archived to view it.\n", version, name)
+		return
+	}
 
 	tpl, err := loadTemplateVersion(name, version)
 	if err != nil {
@@ -511,6 +658,15 @@ func viewVersion() {
 	fmt.Printf("Template %q version %d content:\n\n%s\n", name, version, tpl.Template)
 }
 
+func hasIncludeArchivedFlag(args []string) bool {
+	for _, a := range args {
+		if a == "--all" || a == "--archived" {
+			return true
+		}
+	}
+	return false
+}
+
 func openEditorForInput() (string, error) {
 	editor := os.Getenv("EDITOR")
 	if editor == "" {
@@ -540,8 +696,3 @@ func openEditorForInput() (string, error) {
 
 	return string(content), nil
 }
-
-func getHistoryPath() string {
-	home, _ := os.UserHomeDir()
-	return filepath.Join(home, templateDir, "history.log")
-}
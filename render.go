@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/atotto/clipboard"
+)
+
+// defaultFileSizeCap bounds how much of a file the `file` template helper
+// will inline, so a stray large file can't blow up a generated prompt.
+const defaultFileSizeCap = 1 << 20 // 1MiB
+
+// maxIncludeDepth bounds `include` recursion so a template that includes
+// itself (directly or via a cycle) fails cleanly instead of looping forever.
+const maxIncludeDepth = 8
+
+// Renderer executes a PromptTemplate's body against a FuncMap of helpers
+// (env/file/clip/exec/include plus Sprig-style string basics), so both
+// `generate` and `review --dry-run` share one rendering path. env, file and
+// exec all touch the local machine on a remote-sourced template's behalf, so
+// each requires its own opt-in flag before it will do anything.
+type Renderer struct {
+	AllowExec    bool
+	AllowFile    bool
+	AllowEnv     bool
+	FileSizeCap  int64
+	includeDepth int
+}
+
+func NewRenderer(allowExec, allowFile, allowEnv bool) *Renderer {
+	return &Renderer{
+		AllowExec:   allowExec,
+		AllowFile:   allowFile,
+		AllowEnv:    allowEnv,
+		FileSizeCap: defaultFileSizeCap,
+	}
+}
+
+func (r *Renderer) FuncMap(data map[string]interface{}) template.FuncMap {
+	return template.FuncMap{
+		"env":  r.getEnv,
+		"file": r.readFile,
+		"clip": clipboard.ReadAll,
+		"now":  time.Now,
+		"date": func(layout string) string { return time.Now().Format(layout) },
+		"exec": r.execCommand,
+		"include": func(name string) (string, error) {
+			return r.include(name, data)
+		},
+		"upper": strings.ToUpper,
+		"lower": strings.ToLower,
+		"trim":  strings.TrimSpace,
+		"default": func(def, val string) string {
+			if val == "" {
+				return def
+			}
+			return val
+		},
+		"join":  func(sep string, items []string) string { return strings.Join(items, sep) },
+		"split": func(sep, s string) []string { return strings.Split(s, sep) },
+	}
+}
+
+func (r *Renderer) getEnv(name string) (string, error) {
+	if !r.AllowEnv {
+		return "", fmt.Errorf("env(%q) is disabled; pass --allow-env to permit reading environment variables from templates", name)
+	}
+	return os.Getenv(name), nil
+}
+
+func (r *Renderer) readFile(path string) (string, error) {
+	if !r.AllowFile {
+		return "", fmt.Errorf("file(%q) is disabled; pass --allow-file to permit inlining local files from templates", path)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	cap := r.FileSizeCap
+	if cap <= 0 {
+		cap = defaultFileSizeCap
+	}
+	if info.Size() > cap {
+		return "", fmt.Errorf("file %q is %d bytes, exceeds the %d byte cap", path, info.Size(), cap)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+func (r *Renderer) execCommand(name string, args ...string) (string, error) {
+	if !r.AllowExec {
+		return "", fmt.Errorf("exec(%q) is disabled; pass --allow-exec to permit running commands from templates", name)
+	}
+	out, err := exec.Command(name, args...).Output()
+	if err != nil {
+		return "", fmt.Errorf("exec %q failed: %w", name, err)
+	}
+	return strings.TrimRight(string(out), "\n"), nil
+}
+
+// include composes across saved templates: it loads the named template and
+// renders it with the same data as the including template, so its own
+// variable references are substituted rather than spliced in raw.
+func (r *Renderer) include(name string, data map[string]interface{}) (string, error) {
+	if r.includeDepth >= maxIncludeDepth {
+		return "", fmt.Errorf("include %q: max include depth (%d) exceeded", name, maxIncludeDepth)
+	}
+	tpl, err := loadTemplate(name)
+	if err != nil {
+		return "", fmt.Errorf("include %q: %w", name, err)
+	}
+
+	child := *r
+	child.includeDepth = r.includeDepth + 1
+	return child.Render(tpl, data)
+}
+
+// Render executes tpl's body (with the legacy <input> placeholder
+// normalized) against data using the renderer's FuncMap.
+func (r *Renderer) Render(tpl PromptTemplate, data map[string]interface{}) (string, error) {
+	normalized := strings.ReplaceAll(tpl.Template, "<input>", "{{.Input}}")
+
+	t, err := template.New(tpl.Name).Funcs(r.FuncMap(data)).Parse(normalized)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func getTemplateDataSidecarPath(name string) string {
+	return strings.TrimSuffix(getTemplatePath(name), ".json") + ".data.json"
+}
+
+// loadTemplateDataSidecar loads the optional NAME.data.json file sitting
+// alongside a template, used as a base layer for generate's variable data.
+func loadTemplateDataSidecar(name string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(getTemplateDataSidecarPath(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]interface{}{}, nil
+		}
+		return nil, err
+	}
+	var sidecar map[string]interface{}
+	if err := json.Unmarshal(data, &sidecar); err != nil {
+		return nil, err
+	}
+	return sidecar, nil
+}
+
+// placeholderData builds a dry-run data set for tpl: each declared variable
+// uses its Default when set, otherwise a literal "<Name>" placeholder.
+func placeholderData(tpl PromptTemplate) map[string]interface{} {
+	data := map[string]interface{}{}
+	if len(tpl.Variables) == 0 {
+		data["Input"] = "<Input>"
+		return data
+	}
+	for _, v := range tpl.Variables {
+		if v.Default != "" {
+			data[v.Name] = v.Default
+			continue
+		}
+		data[v.Name] = fmt.Sprintf("<%s>", v.Name)
+	}
+	return data
+}
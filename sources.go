@@ -0,0 +1,350 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const registriesDir = ".promptgen/registries"
+const sourcesFile = ".promptgen/sources.json"
+
+// Source is a configured remote template registry.
+type Source struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	Kind string `json:"kind"` // "git", "tarball" or "http"
+}
+
+func getPromptgenHome() string {
+	home, _ := os.UserHomeDir()
+	return home
+}
+
+func getSourcesPath() string {
+	return filepath.Join(getPromptgenHome(), sourcesFile)
+}
+
+func getRegistryDir(source string) string {
+	return filepath.Join(getPromptgenHome(), registriesDir, source)
+}
+
+func registryTemplatePath(source, name string) string {
+	return filepath.Join(getRegistryDir(source), name+".json")
+}
+
+func loadSources() ([]Source, error) {
+	data, err := os.ReadFile(getSourcesPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var sources []Source
+	if err := json.Unmarshal(data, &sources); err != nil {
+		return nil, err
+	}
+	return sources, nil
+}
+
+func saveSources(sources []Source) error {
+	dir := filepath.Dir(getSourcesPath())
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(sources, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(getSourcesPath(), data, 0644)
+}
+
+func findSource(sources []Source, name string) (Source, bool) {
+	for _, s := range sources {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return Source{}, false
+}
+
+func detectSourceKind(url string) string {
+	if strings.HasSuffix(url, ".tar.gz") || strings.HasSuffix(url, ".tgz") {
+		return "tarball"
+	}
+	if strings.HasPrefix(url, "git@") || strings.HasSuffix(url, ".git") {
+		return "git"
+	}
+	return "http"
+}
+
+// resolveTemplatePath finds the JSON file backing NAME. A "source/name"
+// short-name resolves straight to that registry; any other name checks local
+// templates first, then every configured registry in order.
+func resolveTemplatePath(name string) string {
+	if source, short, ok := strings.Cut(name, "/"); ok {
+		return registryTemplatePath(source, short)
+	}
+
+	local := getTemplatePath(name)
+	if _, err := os.Stat(local); err == nil {
+		return local
+	}
+
+	sources, _ := loadSources()
+	for _, s := range sources {
+		p := registryTemplatePath(s.Name, name)
+		if _, err := os.Stat(p); err == nil {
+			return p
+		}
+	}
+
+	return local
+}
+
+func addSourceCmd() {
+	if len(os.Args) < 5 || os.Args[2] != "add" {
+		fmt.Println("Usage: promptgen source add NAME URL")
+		return
+	}
+	name := os.Args[3]
+	url := os.Args[4]
+
+	sources, err := loadSources()
+	if err != nil {
+		fmt.Println("Error reading sources:", err)
+		return
+	}
+	if _, ok := findSource(sources, name); ok {
+		fmt.Printf("Source %q already exists.\n", name)
+		return
+	}
+
+	sources = append(sources, Source{Name: name, URL: url, Kind: detectSourceKind(url)})
+	if err := saveSources(sources); err != nil {
+		fmt.Println("Error saving sources:", err)
+		return
+	}
+	fmt.Printf("Added source %q (%s).\n", name, url)
+}
+
+func listSourcesCmd() {
+	sources, err := loadSources()
+	if err != nil {
+		fmt.Println("Error reading sources:", err)
+		return
+	}
+	if len(sources) == 0 {
+		fmt.Println("No sources configured.")
+		return
+	}
+	fmt.Println("Sources:")
+	for _, s := range sources {
+		fmt.Printf(" - %s (%s) %s\n", s.Name, s.Kind, s.URL)
+	}
+}
+
+func sourceCmd() {
+	if len(os.Args) < 3 {
+		fmt.Println("Usage: promptgen source add NAME URL | promptgen source list")
+		return
+	}
+	switch os.Args[2] {
+	case "add":
+		addSourceCmd()
+	case "list":
+		listSourcesCmd()
+	default:
+		fmt.Println("Usage: promptgen source add NAME URL | promptgen source list")
+	}
+}
+
+// pullCmd implements `promptgen pull SOURCE[/TEMPLATE]`.
+func pullCmd() {
+	if len(os.Args) < 3 {
+		fmt.Println("Usage: promptgen pull SOURCE[/TEMPLATE]")
+		return
+	}
+	sourceName, template, _ := strings.Cut(os.Args[2], "/")
+
+	sources, err := loadSources()
+	if err != nil {
+		fmt.Println("Error reading sources:", err)
+		return
+	}
+	source, ok := findSource(sources, sourceName)
+	if !ok {
+		fmt.Printf("No such source: %q (use 'promptgen source add')\n", sourceName)
+		return
+	}
+
+	dir := getRegistryDir(source.Name)
+	if err := os.MkdirAll(filepath.Dir(dir), os.ModePerm); err != nil {
+		fmt.Println("Error creating registries directory:", err)
+		return
+	}
+
+	switch source.Kind {
+	case "git":
+		if _, err := os.Stat(dir); err == nil {
+			fmt.Printf("Registry %q already pulled; use 'promptgen sync' to refresh.\n", source.Name)
+			return
+		}
+		cmd := exec.Command("git", "clone", source.URL, dir)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			fmt.Println("git clone failed:", err)
+			return
+		}
+	default: // http/tarball
+		if err := downloadAndExtractTarball(source.URL, dir); err != nil {
+			fmt.Println("Error fetching tarball:", err)
+			return
+		}
+	}
+
+	if template != "" {
+		fmt.Printf("Pulled %q; template %q available as %s/%s.\n", source.Name, template, source.Name, template)
+	} else {
+		fmt.Printf("Pulled source %q into %s.\n", source.Name, dir)
+	}
+}
+
+func downloadAndExtractTarball(url, dest string) error {
+	resp, err := http.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s fetching %s", resp.Status, url)
+	}
+
+	gz, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	if err := os.MkdirAll(dest, os.ModePerm); err != nil {
+		return err
+	}
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(dest, filepath.Clean(hdr.Name))
+		if !strings.HasPrefix(target, filepath.Clean(dest)+string(os.PathSeparator)) {
+			return fmt.Errorf("tarball entry %q escapes destination", hdr.Name)
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, os.ModePerm); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), os.ModePerm); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, os.FileMode(hdr.Mode))
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(f, tr); err != nil {
+				f.Close()
+				return err
+			}
+			f.Close()
+		}
+	}
+}
+
+// syncCmd refreshes every git-backed source already pulled locally.
+func syncCmd() {
+	sources, err := loadSources()
+	if err != nil {
+		fmt.Println("Error reading sources:", err)
+		return
+	}
+
+	for _, s := range sources {
+		dir := getRegistryDir(s.Name)
+		if _, err := os.Stat(dir); err != nil {
+			continue
+		}
+		if s.Kind != "git" {
+			fmt.Printf("Skipping %q: not git-backed, use 'promptgen pull' to refresh.\n", s.Name)
+			continue
+		}
+		cmd := exec.Command("git", "-C", dir, "pull")
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		fmt.Printf("Syncing %q...\n", s.Name)
+		if err := cmd.Run(); err != nil {
+			fmt.Printf("Error syncing %q: %v\n", s.Name, err)
+		}
+	}
+}
+
+// pushCmd commits and pushes local edits made inside a git-backed registry.
+func pushCmd() {
+	if len(os.Args) < 3 {
+		fmt.Println("Usage: promptgen push SOURCE")
+		return
+	}
+	sourceName := os.Args[2]
+
+	sources, err := loadSources()
+	if err != nil {
+		fmt.Println("Error reading sources:", err)
+		return
+	}
+	source, ok := findSource(sources, sourceName)
+	if !ok {
+		fmt.Printf("No such source: %q\n", sourceName)
+		return
+	}
+	if source.Kind != "git" {
+		fmt.Printf("Source %q is not git-backed; push is only supported for git sources.\n", sourceName)
+		return
+	}
+
+	dir := getRegistryDir(source.Name)
+	if _, err := os.Stat(dir); err != nil {
+		fmt.Printf("Source %q has not been pulled yet.\n", sourceName)
+		return
+	}
+
+	commands := [][]string{
+		{"add", "-A"},
+		{"commit", "-m", "promptgen: sync local template edits"},
+		{"push"},
+	}
+	for _, args := range commands {
+		cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			fmt.Printf("git %s failed: %v\n", strings.Join(args, " "), err)
+			return
+		}
+	}
+	fmt.Printf("Pushed local edits for %q.\n", sourceName)
+}
@@ -0,0 +1,433 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// Variable describes one named placeholder in a template's body.
+type Variable struct {
+	Name        string   `json:"name"`
+	Type        string   `json:"type"` // string, multiline, enum, bool, int
+	Description string   `json:"description,omitempty"`
+	Default     string   `json:"default,omitempty"`
+	Enum        []string `json:"enum,omitempty"`
+}
+
+// variableRefPattern is a last-resort fallback for bodies that don't parse
+// as a valid template (e.g. mid-edit); it only catches a bare {{.Name}}.
+var variableRefPattern = regexp.MustCompile(`{{\s*\.(\w+)\s*}}`)
+
+// detectVariableRefs returns every ".Name" field referenced anywhere in
+// body's parsed template tree, in first-seen order — inside pipelines
+// ({{.Name | upper}}), action keywords ({{if gt .Count 10}}), and nested
+// if/range/with blocks, not just a bare {{.Name}}. The legacy "<input>"
+// placeholder is treated as a reference to "Input" so old and new-style
+// templates are handled uniformly.
+func detectVariableRefs(body string) []string {
+	var refs []string
+	seen := map[string]bool{}
+
+	if strings.Contains(body, "<input>") {
+		refs = append(refs, "Input")
+		seen["Input"] = true
+	}
+
+	normalized := strings.ReplaceAll(body, "<input>", "{{.Input}}")
+	parsed, err := parseFieldRefs(normalized)
+	if err != nil {
+		// Body doesn't parse as a valid template yet (e.g. mid-edit); fall
+		// back to a narrow bare-{{.Name}} scan rather than reporting nothing.
+		parsed = nil
+		for _, m := range variableRefPattern.FindAllStringSubmatch(body, -1) {
+			parsed = append(parsed, m[1])
+		}
+	}
+
+	for _, name := range parsed {
+		if !seen[name] {
+			seen[name] = true
+			refs = append(refs, name)
+		}
+	}
+	return refs
+}
+
+// parseFieldRefs parses body as a Go template (using text/template.Parse so
+// builtins like eq/gt/and/or resolve alongside the renderer's own functions)
+// and walks its node tree, collecting the top-level identifier of every
+// ".Name" field reference (first-seen order, duplicates included — callers
+// dedupe).
+func parseFieldRefs(body string) ([]string, error) {
+	t, err := template.New("detect").Funcs((&Renderer{}).FuncMap(nil)).Parse(body)
+	if err != nil {
+		return nil, err
+	}
+
+	var refs []string
+	var walk func(parse.Node)
+	walk = func(n parse.Node) {
+		switch x := n.(type) {
+		case *parse.ListNode:
+			if x == nil {
+				return
+			}
+			for _, c := range x.Nodes {
+				walk(c)
+			}
+		case *parse.ActionNode:
+			walk(x.Pipe)
+		case *parse.IfNode:
+			walk(x.Pipe)
+			walk(x.List)
+			walk(x.ElseList)
+		case *parse.RangeNode:
+			walk(x.Pipe)
+			walk(x.List)
+			walk(x.ElseList)
+		case *parse.WithNode:
+			walk(x.Pipe)
+			walk(x.List)
+			walk(x.ElseList)
+		case *parse.TemplateNode:
+			walk(x.Pipe)
+		case *parse.PipeNode:
+			if x == nil {
+				return
+			}
+			for _, c := range x.Cmds {
+				walk(c)
+			}
+		case *parse.CommandNode:
+			for _, a := range x.Args {
+				walk(a)
+			}
+		case *parse.ChainNode:
+			walk(x.Node)
+		case *parse.FieldNode:
+			if len(x.Ident) > 0 {
+				refs = append(refs, x.Ident[0])
+			}
+		}
+	}
+
+	for _, tmpl := range t.Templates() {
+		if tmpl.Tree != nil {
+			walk(tmpl.Tree.Root)
+		}
+	}
+	return refs, nil
+}
+
+// promptForVariableSchema walks the variable references found in content and
+// interactively asks the author to declare any that aren't already part of
+// existing. Already-declared variables the body still references are kept
+// as-is; declarations the body no longer references are dropped.
+func promptForVariableSchema(reader *bufio.Reader, content string, existing []Variable) []Variable {
+	byName := map[string]Variable{}
+	for _, v := range existing {
+		byName[v.Name] = v
+	}
+
+	var result []Variable
+	for _, name := range detectVariableRefs(content) {
+		if v, ok := byName[name]; ok {
+			result = append(result, v)
+			continue
+		}
+
+		fmt.Printf("\nDeclare variable %q:\n", name)
+		v := Variable{Name: name}
+
+		fmt.Print("  Type (string/multiline/enum/bool/int) [string]: ")
+		typ, _ := reader.ReadString('\n')
+		typ = strings.TrimSpace(typ)
+		if typ == "" {
+			typ = "string"
+		}
+		v.Type = typ
+
+		fmt.Print("  Description: ")
+		desc, _ := reader.ReadString('\n')
+		v.Description = strings.TrimSpace(desc)
+
+		fmt.Print("  Default (blank for none): ")
+		def, _ := reader.ReadString('\n')
+		v.Default = strings.TrimSpace(def)
+
+		if v.Type == "enum" {
+			fmt.Print("  Enum choices (comma separated): ")
+			choices, _ := reader.ReadString('\n')
+			for _, c := range strings.Split(choices, ",") {
+				c = strings.TrimSpace(c)
+				if c != "" {
+					v.Enum = append(v.Enum, c)
+				}
+			}
+		}
+
+		result = append(result, v)
+	}
+	return result
+}
+
+// resolveVariables produces the final value set for a generate run, given
+// values already supplied via key=value args or --vars file, prompting
+// interactively for anything still missing.
+func resolveVariables(tpl PromptTemplate, provided map[string]string) (map[string]interface{}, error) {
+	reader := bufio.NewReader(os.Stdin)
+	data := map[string]interface{}{}
+
+	for _, v := range tpl.Variables {
+		if raw, ok := provided[v.Name]; ok {
+			val, err := coerceVariableValue(v, raw)
+			if err != nil {
+				return nil, err
+			}
+			data[v.Name] = val
+			continue
+		}
+
+		val, err := promptForVariableValue(reader, v)
+		if err != nil {
+			return nil, err
+		}
+		data[v.Name] = val
+	}
+
+	return data, nil
+}
+
+func promptForVariableValue(reader *bufio.Reader, v Variable) (interface{}, error) {
+	switch v.Type {
+	case "multiline":
+		fmt.Printf("%s (opening $EDITOR)...\n", variablePrompt(v))
+		content, err := openEditorForInput()
+		if err != nil {
+			return nil, fmt.Errorf("editor input for %q: %w", v.Name, err)
+		}
+		content = strings.TrimRight(content, "\n")
+		if content == "" {
+			content = v.Default
+		}
+		return content, nil
+	case "enum":
+		for {
+			fmt.Printf("%s [%s]: ", variablePrompt(v), strings.Join(v.Enum, "/"))
+			line, _ := reader.ReadString('\n')
+			line = strings.TrimSpace(line)
+			if line == "" {
+				line = v.Default
+			}
+			if isEnumChoice(v.Enum, line) {
+				return line, nil
+			}
+			fmt.Printf("  %q is not one of: %s\n", line, strings.Join(v.Enum, ", "))
+		}
+	case "bool":
+		for {
+			fmt.Printf("%s (y/n) [%s]: ", variablePrompt(v), v.Default)
+			line, _ := reader.ReadString('\n')
+			line = strings.TrimSpace(line)
+			if line == "" {
+				line = v.Default
+			}
+			b, err := parseBool(line)
+			if err == nil {
+				return b, nil
+			}
+			fmt.Println("  Please answer y or n.")
+		}
+	case "int":
+		for {
+			fmt.Printf("%s [%s]: ", variablePrompt(v), v.Default)
+			line, _ := reader.ReadString('\n')
+			line = strings.TrimSpace(line)
+			if line == "" {
+				line = v.Default
+			}
+			n, err := strconv.Atoi(line)
+			if err == nil {
+				return n, nil
+			}
+			fmt.Println("  Please enter a whole number.")
+		}
+	default: // string
+		fmt.Printf("%s [%s]: ", variablePrompt(v), v.Default)
+		line, _ := reader.ReadString('\n')
+		line = strings.TrimSpace(line)
+		if line == "" {
+			line = v.Default
+		}
+		return line, nil
+	}
+}
+
+func coerceVariableValue(v Variable, raw string) (interface{}, error) {
+	switch v.Type {
+	case "enum":
+		if !isEnumChoice(v.Enum, raw) {
+			return nil, fmt.Errorf("%q is not a valid value for %q, expected one of: %s", raw, v.Name, strings.Join(v.Enum, ", "))
+		}
+		return raw, nil
+	case "bool":
+		return parseBool(raw)
+	case "int":
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a valid int for %q", raw, v.Name)
+		}
+		return n, nil
+	default:
+		return raw, nil
+	}
+}
+
+// coerceHistoryVariables re-types variable values that have round-tripped
+// through JSON (and so had any int values decoded as float64) back into the
+// types tpl's schema declares, so replays of numeric comparisons behave the
+// same as the original generate run.
+func coerceHistoryVariables(tpl PromptTemplate, vars map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(vars))
+	for k, v := range vars {
+		result[k] = v
+	}
+	for _, decl := range tpl.Variables {
+		if decl.Type != "int" {
+			continue
+		}
+		if f, ok := result[decl.Name].(float64); ok {
+			result[decl.Name] = int(f)
+		}
+	}
+	return result
+}
+
+func isEnumChoice(choices []string, val string) bool {
+	for _, c := range choices {
+		if c == val {
+			return true
+		}
+	}
+	return false
+}
+
+func parseBool(s string) (bool, error) {
+	switch strings.ToLower(s) {
+	case "y", "yes", "true":
+		return true, nil
+	case "n", "no", "false":
+		return false, nil
+	default:
+		return false, fmt.Errorf("not a bool: %q", s)
+	}
+}
+
+func variablePrompt(v Variable) string {
+	if v.Description != "" {
+		return fmt.Sprintf("%s (%s)", v.Name, v.Description)
+	}
+	return v.Name
+}
+
+// parseVariableArgs splits generate's trailing args into key=value pairs, a
+// --vars file path, and any remaining positional/legacy args.
+func parseVariableArgs(args []string) (values map[string]string, varsFile string, rest []string) {
+	values = map[string]string{}
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		switch {
+		case a == "--vars":
+			if i+1 < len(args) {
+				varsFile = args[i+1]
+				i++
+			}
+		case strings.HasPrefix(a, "--vars="):
+			varsFile = strings.TrimPrefix(a, "--vars=")
+		case strings.Contains(a, "=") && !strings.HasPrefix(a, "-"):
+			parts := strings.SplitN(a, "=", 2)
+			values[parts[0]] = parts[1]
+		default:
+			rest = append(rest, a)
+		}
+	}
+	return values, varsFile, rest
+}
+
+func loadVarsFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	values := map[string]string{}
+	for k, v := range raw {
+		values[k] = fmt.Sprintf("%v", v)
+	}
+	return values, nil
+}
+
+// testTemplateSchema validates that a template's declared Variables agree
+// with what its body actually references, reporting any mismatch.
+func testTemplateSchema() {
+	if len(os.Args) < 3 {
+		fmt.Println("Usage: promptgen test NAME")
+		return
+	}
+	name := os.Args[2]
+
+	tpl, err := loadTemplate(name)
+	if err != nil {
+		fmt.Printf("Template %q not found.\n", name)
+		return
+	}
+
+	declared := map[string]bool{}
+	for _, v := range tpl.Variables {
+		declared[v.Name] = true
+	}
+
+	refs := detectVariableRefs(tpl.Template)
+	referenced := map[string]bool{}
+	for _, r := range refs {
+		referenced[r] = true
+	}
+
+	var undeclared, unused []string
+	for _, r := range refs {
+		if !declared[r] {
+			undeclared = append(undeclared, r)
+		}
+	}
+	for name := range declared {
+		if !referenced[name] {
+			unused = append(unused, name)
+		}
+	}
+	sort.Strings(undeclared)
+	sort.Strings(unused)
+
+	if len(undeclared) == 0 && len(unused) == 0 {
+		fmt.Printf("Template %q: schema and body agree (%d variable(s)).\n", name, len(tpl.Variables))
+		return
+	}
+
+	fmt.Printf("Template %q: schema/body mismatch\n", name)
+	if len(undeclared) > 0 {
+		fmt.Println("  Undeclared (used in body, missing from schema):", strings.Join(undeclared, ", "))
+	}
+	if len(unused) > 0 {
+		fmt.Println("  Unused (declared in schema, not used in body):", strings.Join(unused, ", "))
+	}
+}
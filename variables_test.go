@@ -0,0 +1,138 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDetectVariableRefs(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{"no refs", "just plain text", nil},
+		{"legacy input", "Summarize: <input>", []string{"Input"}},
+		{"single var", "Hello {{.Name}}!", []string{"Name"}},
+		{"multiple vars in order", "{{.A}} then {{.B}} then {{.A}}", []string{"A", "B"}},
+		{"legacy and explicit", "<input> plus {{.Extra}}", []string{"Input", "Extra"}},
+		{"var in if condition", "{{if gt .Count 10}}big{{else}}small{{end}}", []string{"Count"}},
+		{"var in bare if", "{{if .Flag}}on{{end}}", []string{"Flag"}},
+		{"var in pipeline", "{{.Name | upper}}", []string{"Name"}},
+		{"vars in range and with", "{{range .Items}}{{.}}{{end}}{{with .Extra}}{{.}}{{end}}", []string{"Items", "Extra"}},
+		{"unparseable body falls back to regex", "{{if .Broken}", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := detectVariableRefs(tt.body)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("detectVariableRefs(%q) = %v, want %v", tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCoerceVariableValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		v       Variable
+		raw     string
+		want    interface{}
+		wantErr bool
+	}{
+		{"string passthrough", Variable{Name: "s", Type: "string"}, "hello", "hello", false},
+		{"valid int", Variable{Name: "n", Type: "int"}, "5", 5, false},
+		{"invalid int", Variable{Name: "n", Type: "int"}, "five", nil, true},
+		{"valid bool yes", Variable{Name: "b", Type: "bool"}, "yes", true, false},
+		{"valid bool no", Variable{Name: "b", Type: "bool"}, "n", false, false},
+		{"invalid bool", Variable{Name: "b", Type: "bool"}, "maybe", nil, true},
+		{"valid enum", Variable{Name: "e", Type: "enum", Enum: []string{"a", "b"}}, "b", "b", false},
+		{"invalid enum", Variable{Name: "e", Type: "enum", Enum: []string{"a", "b"}}, "c", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := coerceVariableValue(tt.v, tt.raw)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("coerceVariableValue(%+v, %q) expected error, got nil", tt.v, tt.raw)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("coerceVariableValue(%+v, %q) unexpected error: %v", tt.v, tt.raw, err)
+			}
+			if got != tt.want {
+				t.Errorf("coerceVariableValue(%+v, %q) = %v, want %v", tt.v, tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCoerceHistoryVariables(t *testing.T) {
+	tpl := PromptTemplate{
+		Variables: []Variable{
+			{Name: "Count", Type: "int"},
+			{Name: "Label", Type: "string"},
+		},
+	}
+	// Simulates what json.Unmarshal produces for a history record: numbers
+	// decode as float64 regardless of the original Go type.
+	stored := map[string]interface{}{"Count": float64(5), "Label": "ok"}
+
+	got := coerceHistoryVariables(tpl, stored)
+
+	if got["Count"] != 5 {
+		t.Errorf("Count = %v (%T), want int 5", got["Count"], got["Count"])
+	}
+	if got["Label"] != "ok" {
+		t.Errorf("Label = %v, want %q", got["Label"], "ok")
+	}
+	// original map must be left untouched
+	if _, ok := stored["Count"].(float64); !ok {
+		t.Errorf("coerceHistoryVariables mutated the input map")
+	}
+}
+
+func TestParseBool(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    bool
+		wantErr bool
+	}{
+		{"y", true, false},
+		{"yes", true, false},
+		{"true", true, false},
+		{"n", false, false},
+		{"no", false, false},
+		{"false", false, false},
+		{"nope", false, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseBool(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseBool(%q) expected error, got nil", tt.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseBool(%q) unexpected error: %v", tt.in, err)
+		}
+		if got != tt.want {
+			t.Errorf("parseBool(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestIsEnumChoice(t *testing.T) {
+	choices := []string{"a", "b", "c"}
+	if !isEnumChoice(choices, "b") {
+		t.Error("expected \"b\" to be a valid choice")
+	}
+	if isEnumChoice(choices, "d") {
+		t.Error("expected \"d\" to be an invalid choice")
+	}
+}